@@ -0,0 +1,300 @@
+package pixelgl
+
+import (
+	"math"
+	"sync"
+	"unsafe"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// halfFloatVertexSupported caches whether GL_ARB_half_float_vertex is available, gating the
+// Half2/Half3/Half4 attribute types.
+//
+// Checked lazily, on the GL thread, the first time it's needed (see ensureHalfFloatChecked)
+// rather than at package init, since at init time there is no current GL context yet.
+var (
+	halfFloatOnce            sync.Once
+	halfFloatVertexSupported bool
+)
+
+func ensureHalfFloatChecked(parent Doer) {
+	halfFloatOnce.Do(func() {
+		parent.Do(func(Context) {
+			Do(func() {
+				halfFloatVertexSupported = checkHalfFloatVertexSupport()
+			})
+		})
+	})
+}
+
+func checkHalfFloatVertexSupport() bool {
+	var numExtensions int32
+	gl.GetIntegerv(gl.NUM_EXTENSIONS, &numExtensions)
+	for i := int32(0); i < numExtensions; i++ {
+		if gl.GoStr(gl.GetStringi(gl.EXTENSIONS, uint32(i))) == "GL_ARB_half_float_vertex" {
+			return true
+		}
+	}
+	return false
+}
+
+// attrLayout describes how an AttrType is laid out in a vertex buffer and bound to a shader's
+// attribute location via glVertexAttribPointer/glVertexAttribIPointer.
+type attrLayout struct {
+	components int32
+	glType     uint32
+	normalized bool
+	integer    bool // true: bind via glVertexAttribIPointer instead of glVertexAttribPointer
+}
+
+// layoutOf returns the GL binding layout for typ. It panics for attribute types that have no
+// vertex buffer representation.
+func layoutOf(typ AttrType) attrLayout {
+	switch typ {
+	case Float:
+		return attrLayout{components: 1, glType: gl.FLOAT}
+	case Vec2:
+		return attrLayout{components: 2, glType: gl.FLOAT}
+	case Vec3:
+		return attrLayout{components: 3, glType: gl.FLOAT}
+	case Vec4:
+		return attrLayout{components: 4, glType: gl.FLOAT}
+	case Int:
+		return attrLayout{components: 1, glType: gl.INT, integer: true}
+	case IVec2:
+		return attrLayout{components: 2, glType: gl.INT, integer: true}
+	case IVec3:
+		return attrLayout{components: 3, glType: gl.INT, integer: true}
+	case IVec4:
+		return attrLayout{components: 4, glType: gl.INT, integer: true}
+	case Short:
+		return attrLayout{components: 1, glType: gl.SHORT}
+	case UShort:
+		return attrLayout{components: 1, glType: gl.UNSIGNED_SHORT}
+	case UByte4Color:
+		return attrLayout{components: 4, glType: gl.UNSIGNED_BYTE, normalized: true}
+	case Half2:
+		return attrLayout{components: 2, glType: gl.HALF_FLOAT, normalized: false}
+	case Half3:
+		return attrLayout{components: 3, glType: gl.HALF_FLOAT, normalized: false}
+	case Half4:
+		return attrLayout{components: 4, glType: gl.HALF_FLOAT, normalized: false}
+	default:
+		panic("vertex array: invalid vertex attribute type")
+	}
+}
+
+// validAttrType reports whether typ is a type NewVertexArray knows how to lay out, rejecting
+// Half2/Half3/Half4 up front when the context lacks GL_ARB_half_float_vertex.
+func validAttrType(parent Doer, typ AttrType) bool {
+	ensureHalfFloatChecked(parent)
+
+	switch typ {
+	case Float, Vec2, Vec3, Vec4, Int, IVec2, IVec3, IVec4, Short, UShort, UByte4Color:
+		return true
+	case Half2, Half3, Half4:
+		return halfFloatVertexSupported
+	default:
+		return false
+	}
+}
+
+// packAttrValue writes value (whose Go type must match attr.Type, see SetVertexAttr) into a
+// byte buffer in this attribute type's vertex buffer representation, and returns it.
+func packAttrValue(typ AttrType, value interface{}) []byte {
+	switch typ {
+	case Float:
+		v := value.(float32)
+		return structBytes(unsafe.Pointer(&v), 4)
+	case Vec2:
+		v := value.(mgl32.Vec2)
+		return structBytes(unsafe.Pointer(&v), 8)
+	case Vec3:
+		v := value.(mgl32.Vec3)
+		return structBytes(unsafe.Pointer(&v), 12)
+	case Vec4:
+		v := value.(mgl32.Vec4)
+		return structBytes(unsafe.Pointer(&v), 16)
+	case Int:
+		v := value.(int32)
+		return structBytes(unsafe.Pointer(&v), 4)
+	case IVec2:
+		v := value.([2]int32)
+		return structBytes(unsafe.Pointer(&v), 8)
+	case IVec3:
+		v := value.([3]int32)
+		return structBytes(unsafe.Pointer(&v), 12)
+	case IVec4:
+		v := value.([4]int32)
+		return structBytes(unsafe.Pointer(&v), 16)
+	case Short:
+		v := value.(int16)
+		return structBytes(unsafe.Pointer(&v), 2)
+	case UShort:
+		v := value.(uint16)
+		return structBytes(unsafe.Pointer(&v), 2)
+	case UByte4Color:
+		v := value.([4]uint8)
+		return structBytes(unsafe.Pointer(&v), 4)
+	case Half2:
+		v := value.(mgl32.Vec2)
+		return float32ToHalfBytes(v[0], v[1])
+	case Half3:
+		v := value.(mgl32.Vec3)
+		return float32ToHalfBytes(v[0], v[1], v[2])
+	case Half4:
+		v := value.(mgl32.Vec4)
+		return float32ToHalfBytes(v[0], v[1], v[2], v[3])
+	default:
+		panic("vertex array: invalid vertex attribute type")
+	}
+}
+
+// unpackAttrValue is the inverse of packAttrValue: it reads this attribute type's vertex buffer
+// representation out of data and returns it as the Go type SetVertexAttr accepts.
+func unpackAttrValue(typ AttrType, data []byte) interface{} {
+	switch typ {
+	case Float:
+		var v float32
+		copy(structBytes(unsafe.Pointer(&v), 4), data)
+		return v
+	case Vec2:
+		var v mgl32.Vec2
+		copy(structBytes(unsafe.Pointer(&v), 8), data)
+		return v
+	case Vec3:
+		var v mgl32.Vec3
+		copy(structBytes(unsafe.Pointer(&v), 12), data)
+		return v
+	case Vec4:
+		var v mgl32.Vec4
+		copy(structBytes(unsafe.Pointer(&v), 16), data)
+		return v
+	case Int:
+		var v int32
+		copy(structBytes(unsafe.Pointer(&v), 4), data)
+		return v
+	case IVec2:
+		var v [2]int32
+		copy(structBytes(unsafe.Pointer(&v), 8), data)
+		return v
+	case IVec3:
+		var v [3]int32
+		copy(structBytes(unsafe.Pointer(&v), 12), data)
+		return v
+	case IVec4:
+		var v [4]int32
+		copy(structBytes(unsafe.Pointer(&v), 16), data)
+		return v
+	case Short:
+		var v int16
+		copy(structBytes(unsafe.Pointer(&v), 2), data)
+		return v
+	case UShort:
+		var v uint16
+		copy(structBytes(unsafe.Pointer(&v), 2), data)
+		return v
+	case UByte4Color:
+		var v [4]uint8
+		copy(structBytes(unsafe.Pointer(&v), 4), data)
+		return v
+	case Half2:
+		return mgl32.Vec2{halfBitsToFloat32(data, 0), halfBitsToFloat32(data, 2)}
+	case Half3:
+		return mgl32.Vec3{halfBitsToFloat32(data, 0), halfBitsToFloat32(data, 2), halfBitsToFloat32(data, 4)}
+	case Half4:
+		return mgl32.Vec4{halfBitsToFloat32(data, 0), halfBitsToFloat32(data, 2), halfBitsToFloat32(data, 4), halfBitsToFloat32(data, 6)}
+	default:
+		panic("vertex array: invalid vertex attribute type")
+	}
+}
+
+func structBytes(p unsafe.Pointer, size int) []byte {
+	return (*[1 << 30]byte)(p)[:size:size]
+}
+
+func float32ToHalfBytes(values ...float32) []byte {
+	out := make([]byte, 2*len(values))
+	for i, f := range values {
+		h := float32ToHalfBits(f)
+		out[2*i] = byte(h)
+		out[2*i+1] = byte(h >> 8)
+	}
+	return out
+}
+
+func halfBitsToFloat32(data []byte, byteOffset int) float32 {
+	h := uint16(data[byteOffset]) | uint16(data[byteOffset+1])<<8
+	return halfToFloat32(h)
+}
+
+// float32ToHalfBits converts an IEEE-754 float32 to an IEEE-754-2008 binary16 (half float), used
+// for the GL_HALF_FLOAT vertex attribute types. Rounding is round-to-nearest-even; values too
+// small to represent even as a half denormal flush to zero, and values too large saturate to
+// half infinity. NaNs are preserved as NaN.
+func float32ToHalfBits(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16((bits >> 16) & 0x8000)
+	mant := bits & 0x7fffff
+	exp := int32((bits>>23)&0xff) - 127
+
+	switch {
+	case bits&0x7fffffff == 0:
+		return sign
+	case exp > 15:
+		if exp == 128 && mant != 0 {
+			return sign | 0x7e00 // NaN
+		}
+		return sign | 0x7c00 // overflow or infinity
+	case exp < -25:
+		return sign // too small to round up to the smallest denormal
+	case exp < -14:
+		// Result is a half denormal: shift the 24-bit significand (with its implicit leading
+		// 1 bit) right until it lines up with the fixed -14 denormal exponent, rounding the
+		// bits shifted out to nearest, ties to even.
+		sig := mant | 0x800000
+		rshift := uint32(-exp - 1)
+		halfMant := sig >> rshift
+		roundBit := (sig >> (rshift - 1)) & 1
+		sticky := sig & (1<<(rshift-1) - 1)
+		if roundBit != 0 && (sticky != 0 || halfMant&1 != 0) {
+			halfMant++
+		}
+		return sign | uint16(halfMant)
+	default:
+		halfExp := uint16(exp + 15)
+		halfMant := uint16(mant >> 13)
+		roundBit := mant & (1 << 12)
+		sticky := mant & (1<<12 - 1)
+		if roundBit != 0 && (sticky != 0 || halfMant&1 != 0) {
+			halfMant++
+			if halfMant == 0x400 {
+				halfMant = 0
+				halfExp++
+			}
+		}
+		if halfExp >= 0x1f {
+			return sign | 0x7c00
+		}
+		return sign | halfExp<<10 | halfMant
+	}
+}
+
+// halfToFloat32 converts an IEEE-754-2008 binary16 (half float) to an IEEE-754 float32.
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h&0x7c00) >> 10
+	mant := uint32(h & 0x3ff)
+
+	switch exp {
+	case 0:
+		// Zero or a half denormal: mant * 2^-24, which is always exact as a float32.
+		return math.Float32frombits(sign | math.Float32bits(float32(mant)/(1<<24)))
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	default:
+		return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+	}
+}
@@ -0,0 +1,30 @@
+package pixelgl
+
+import "testing"
+
+func TestFloat32ToHalfBitsSmallestDenormalBoundary(t *testing.T) {
+	const (
+		smallestDenormal = 1.0 / (1 << 24) // 2^-24, encodes as 0x0001
+		boundary         = 1.0 / (1 << 25) // 2^-25, exactly halfway between 0 and smallestDenormal
+	)
+
+	tests := []struct {
+		name string
+		f    float32
+		want uint16
+	}{
+		{"just above halfway rounds up to smallest denormal", boundary * 1.01, 0x0001},
+		{"exactly halfway rounds to even (zero)", boundary, 0x0000},
+		{"just below halfway rounds down to zero", boundary * 0.99, 0x0000},
+		{"smallest denormal itself", smallestDenormal, 0x0001},
+		{"negative mirrors the positive boundary", -boundary * 1.01, 0x8001},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := float32ToHalfBits(tt.f); got != tt.want {
+				t.Errorf("float32ToHalfBits(%v) = 0x%04x, want 0x%04x", tt.f, got, tt.want)
+			}
+		})
+	}
+}
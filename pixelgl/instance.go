@@ -0,0 +1,104 @@
+package pixelgl
+
+import (
+	"unsafe"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/pkg/errors"
+)
+
+// SetInstanceFormat configures a vertex array for instanced rendering: format describes the
+// per-instance attributes, which live in their own vertex buffer and advance once per instance
+// (a GL vertex attrib divisor of 1) instead of once per vertex.
+//
+// Call SetInstances to allocate storage for the per-instance buffer and SetInstanceAttr to fill
+// it in, then DrawInstanced to draw. Calling SetInstanceFormat again replaces the previous
+// per-instance layout and invalidates any cached VAOs built against it.
+func (va *VertexArray) SetInstanceFormat(format AttrFormat) error {
+	va.instanceFormat = format
+	va.instanceStride = format.Size()
+	va.instanceOffset = make(map[string]int)
+
+	offset := 0
+	for name, typ := range format {
+		if !validAttrType(va.parent, typ) {
+			return errors.New("failed to set instance format: invalid vertex format: invalid attribute type")
+		}
+		va.instanceOffset[name] = offset
+		offset += typ.Size()
+	}
+
+	va.parent.Do(func(ctx Context) {
+		DoNoBlock(func() {
+			if va.ivbo.obj == 0 {
+				va.ivbo = binder{
+					restoreLoc: gl.ARRAY_BUFFER_BINDING,
+					bindFunc: func(obj uint32) {
+						gl.BindBuffer(gl.ARRAY_BUFFER, obj)
+					},
+				}
+				gl.GenBuffers(1, &va.ivbo.obj)
+			}
+			ctx.vaoCache().invalidateVertexArray(va)
+		})
+	})
+
+	return nil
+}
+
+// SetInstances allocates storage in the per-instance buffer for n instances, discarding any
+// previous contents. SetInstanceFormat must be called first.
+func (va *VertexArray) SetInstances(n int) {
+	va.instanceNum = n
+
+	DoNoBlock(func() {
+		va.ivbo.bind()
+		emptyData := make([]byte, n*va.instanceStride)
+		gl.BufferData(gl.ARRAY_BUFFER, len(emptyData), gl.Ptr(emptyData), uint32(DynamicUsage))
+		va.ivbo.restore()
+	})
+}
+
+// SetInstanceAttr sets the value of the specified per-instance attribute of the specified
+// instance. Value types follow the same rules as SetVertexAttr.
+//
+// If the instance attribute does not exist, this method returns false. If the instance is out
+// of range, this method panics.
+func (va *VertexArray) SetInstanceAttr(instance int, attr Attr, value interface{}) (ok bool) {
+	if instance < 0 || instance >= va.instanceNum {
+		panic("set instance attr: invalid instance index")
+	}
+
+	if !va.instanceFormat.Contains(attr) {
+		return false
+	}
+
+	offset := va.instanceStride*instance + va.instanceOffset[attr.Name]
+	packed := packAttrValue(attr.Type, value)
+
+	DoNoBlock(func() {
+		va.ivbo.bind()
+		gl.BufferSubData(gl.ARRAY_BUFFER, offset, len(packed), unsafe.Pointer(&packed[0]))
+		va.ivbo.restore()
+	})
+
+	return true
+}
+
+// DrawInstanced draws va.instanceNum instances of the vertex array with a single
+// glDrawElementsInstanced call (glDrawArraysInstanced if the vertex array has no indices),
+// advancing the per-instance attributes set up by SetInstanceFormat once per instance instead of
+// once per vertex.
+func (va *VertexArray) DrawInstanced() {
+	va.parent.Do(func(ctx Context) {
+		vao := va.bindVAO(ctx)
+		DoNoBlock(func() {
+			if va.indexed {
+				gl.DrawElementsInstanced(uint32(va.mode), int32(va.indexNum), gl.UNSIGNED_INT, gl.PtrOffset(0), int32(va.instanceNum))
+			} else {
+				gl.DrawArraysInstanced(uint32(va.mode), 0, int32(va.vertexNum), int32(va.instanceNum))
+			}
+			vao.restore()
+		})
+	})
+}
@@ -0,0 +1,127 @@
+package pixelgl
+
+import (
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// vaoKey identifies a single (vertex array, shader) pairing. A VertexArray's attribute locations
+// depend on the program it's drawn with, so each pairing needs its own GL VAO.
+//
+// Keyed by the Shader itself rather than its GL program id: GL recycles program ids, so a
+// cached entry surviving past its shader's deletion could otherwise be handed to a completely
+// unrelated shader that's later assigned the same id, binding the wrong attribute locations.
+// Keying on the Shader value sidesteps that regardless of when (or whether) Shader.Delete gets
+// around to calling invalidateShader.
+type vaoKey struct {
+	va     *VertexArray
+	shader Shader
+}
+
+// vaoCache lazily creates and memoizes a GL vertex array object for every (VertexArray, shader
+// program) pair a Context draws. It lives on the Context, so all VertexArrays sharing a Context
+// share the cache.
+type vaoCache struct {
+	vaos map[vaoKey]uint32
+}
+
+func newVAOCache() *vaoCache {
+	return &vaoCache{
+		vaos: make(map[vaoKey]uint32),
+	}
+}
+
+// vaoFor returns the GL vertex array object binding va's buffers to the attribute locations of
+// the context's current shader, creating and configuring it on first use.
+func (c *vaoCache) vaoFor(va *VertexArray, ctx Context) uint32 {
+	shader := ctx.Shader()
+	program := shader.ID()
+	key := vaoKey{va: va, shader: shader}
+
+	if vao, ok := c.vaos[key]; ok {
+		return vao
+	}
+
+	var vao uint32
+	gl.GenVertexArrays(1, &vao)
+	gl.BindVertexArray(vao)
+
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, va.ebo.obj)
+
+	for bi := range va.bindings {
+		vb := &va.bindings[bi]
+		gl.BindBuffer(gl.ARRAY_BUFFER, vb.vbo.obj)
+		bindAttribs(program, vb.format, vb.stride, vb.offset, 0)
+	}
+
+	if va.instanceFormat != nil {
+		gl.BindBuffer(gl.ARRAY_BUFFER, va.ivbo.obj)
+		bindAttribs(program, va.instanceFormat, va.instanceStride, va.instanceOffset, 1)
+	}
+
+	gl.BindVertexArray(0)
+
+	c.vaos[key] = vao
+	return vao
+}
+
+// bindAttribs binds every attribute of format, assumed to already live in the currently bound
+// ARRAY_BUFFER at the given stride/offsets, to its location in program, with the given vertex
+// attrib divisor (0 = per-vertex, 1 = per-instance).
+func bindAttribs(program uint32, format AttrFormat, stride int, offset map[string]int, divisor uint32) {
+	for name, typ := range format {
+		loc := gl.GetAttribLocation(program, gl.Str(name+"\x00"))
+		if loc < 0 {
+			continue
+		}
+
+		layout := layoutOf(typ)
+		if layout.integer {
+			gl.VertexAttribIPointer(
+				uint32(loc),
+				layout.components,
+				layout.glType,
+				int32(stride),
+				gl.PtrOffset(offset[name]),
+			)
+		} else {
+			gl.VertexAttribPointer(
+				uint32(loc),
+				layout.components,
+				layout.glType,
+				layout.normalized,
+				int32(stride),
+				gl.PtrOffset(offset[name]),
+			)
+		}
+		gl.EnableVertexAttribArray(uint32(loc))
+		if divisor != 0 {
+			gl.VertexAttribDivisor(uint32(loc), divisor)
+		}
+	}
+}
+
+// invalidateShader drops every cached VAO built against the given shader, freeing their GL
+// objects. Shader.Delete should call this before calling gl.DeleteProgram, so the cache doesn't
+// hold a dangling reference to a Shader value that no longer owns a live program; vaoKey's
+// identity-based keying (see above) already keeps a deleted shader's entries from being
+// misattributed to whatever program id GL reassigns next, so this is a cleanup to avoid leaking
+// VAOs, not a correctness requirement.
+func (c *vaoCache) invalidateShader(shader Shader) {
+	for key, vao := range c.vaos {
+		if key.shader == shader {
+			gl.DeleteVertexArrays(1, &vao)
+			delete(c.vaos, key)
+		}
+	}
+}
+
+// invalidateVertexArray drops every cached VAO built from the given vertex array. Call this when
+// the vertex array itself is deleted.
+func (c *vaoCache) invalidateVertexArray(va *VertexArray) {
+	for key, vao := range c.vaos {
+		if key.va == va {
+			gl.DeleteVertexArrays(1, &vao)
+			delete(c.vaos, key)
+		}
+	}
+}
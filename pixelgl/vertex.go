@@ -1,10 +1,10 @@
 package pixelgl
 
 import (
+	"sync"
 	"unsafe"
 
 	"github.com/go-gl/gl/v3.3-core/gl"
-	"github.com/go-gl/mathgl/mgl32"
 	"github.com/pkg/errors"
 )
 
@@ -22,106 +22,212 @@ const (
 	StreamUsage VertexUsage = gl.STREAM_DRAW
 )
 
-// VertexArray is an OpenGL vertex array object that also holds it's own vertex buffer object.
+// BufferBinding assigns a subset of a vertex array's attributes to their own vertex buffer,
+// instead of all attributes sharing one interleaved buffer. This is useful when attributes change
+// at different rates, e.g. streaming positions but static colors/UVs: each binding can have its
+// own VertexUsage so the driver doesn't have to re-upload data that hasn't changed.
+type BufferBinding struct {
+	Format AttrFormat
+	Usage  VertexUsage
+}
+
+// vertexBinding is the resolved, constructed form of a BufferBinding: its own VBO plus the
+// stride/offset layout of the attributes assigned to it.
+type vertexBinding struct {
+	vbo    binder
+	format AttrFormat
+	stride int
+	offset map[string]int
+	usage  VertexUsage
+}
+
+// VertexArray is an OpenGL vertex array object that also holds it's own vertex buffer object(s).
 // From the user's points of view, VertexArray is an array of vertices that can be drawn.
 type VertexArray struct {
 	parent              Doer
-	vao, vbo, ebo       binder
+	bindings            []vertexBinding
+	attrBinding         map[string]int // attribute name -> index into bindings
+	ebo                 binder
 	vertexNum, indexNum int
 	format              AttrFormat
-	usage               VertexUsage
-	stride              int
-	offset              map[string]int
+
+	mapped   []byte // CPU-side mirror of bindings[0]'s mapped range, valid only between Begin and End
+	mapping  bool
+	dirtyMin int
+	dirtyMax int
+
+	ivbo           binder
+	instanceFormat AttrFormat
+	instanceStride int
+	instanceOffset map[string]int
+	instanceNum    int
+
+	mode    PrimitiveMode
+	indexed bool
+}
+
+// PrimitiveMode specifies how a VertexArray's vertices are assembled into primitives for drawing.
+type PrimitiveMode int
+
+const (
+	// Points draws every vertex as a separate point.
+	Points PrimitiveMode = gl.POINTS
+
+	// Lines draws every pair of vertices as a separate line segment.
+	Lines PrimitiveMode = gl.LINES
+
+	// LineStrip draws a connected sequence of line segments, from the first vertex to the last.
+	LineStrip PrimitiveMode = gl.LINE_STRIP
+
+	// LineLoop draws a connected sequence of line segments, then closes it with a final segment
+	// back to the first vertex.
+	LineLoop PrimitiveMode = gl.LINE_LOOP
+
+	// Triangles draws every three vertices as a separate triangle. This is the default mode.
+	Triangles PrimitiveMode = gl.TRIANGLES
+
+	// TriangleStrip draws a connected strip of triangles, each sharing an edge with the previous one.
+	TriangleStrip PrimitiveMode = gl.TRIANGLE_STRIP
+
+	// TriangleFan draws a connected fan of triangles sharing the first vertex.
+	TriangleFan PrimitiveMode = gl.TRIANGLE_FAN
+)
+
+// indexMultiple returns the number indices must be a non-zero multiple of for this primitive
+// mode, or 0 if there's no such constraint (strip/fan/loop modes just need enough vertices).
+func (mode PrimitiveMode) indexMultiple() int {
+	switch mode {
+	case Points:
+		return 1
+	case Lines:
+		return 2
+	case Triangles:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// mapBufferRangeSupported caches whether GL_ARB_map_buffer_range is available, so that
+// Begin/End can transparently fall back to glBufferSubData on contexts that lack it.
+//
+// This is checked lazily, on the GL thread, the first time Begin runs, rather than at package
+// init: at init time there is no current GL context yet (and the function pointer table isn't
+// even loaded), so querying extensions then would either crash or freeze the result to a bogus
+// value forever.
+var (
+	mapBufferRangeOnce      sync.Once
+	mapBufferRangeSupported bool
+)
+
+func ensureMapBufferRangeChecked(parent Doer) {
+	mapBufferRangeOnce.Do(func() {
+		parent.Do(func(Context) {
+			Do(func() {
+				mapBufferRangeSupported = checkMapBufferRangeSupport()
+			})
+		})
+	})
+}
+
+func checkMapBufferRangeSupport() bool {
+	var numExtensions int32
+	gl.GetIntegerv(gl.NUM_EXTENSIONS, &numExtensions)
+	for i := int32(0); i < numExtensions; i++ {
+		if gl.GoStr(gl.GetStringi(gl.EXTENSIONS, uint32(i))) == "GL_ARB_map_buffer_range" {
+			return true
+		}
+	}
+	return false
 }
 
 // NewVertexArray creates a new empty vertex array and wraps another Doer around it.
 //
 // You cannot specify vertex attributes in this constructor, only their count. Use SetVertexAttribute* methods to
 // set the vertex attributes. Use indices to specify how you want to combine vertices into triangles.
+//
+// A VertexArray only owns its vertex and element buffers; the actual GL vertex array objects
+// binding them to attribute locations are created and memoized lazily, per shader program, by
+// the Context's vaoCache. This lets the same VertexArray be drawn correctly under more than one
+// shader.
+//
+// This is a convenience wrapper around NewVertexArrayMulti for the common case of a single,
+// interleaved vertex buffer.
 func NewVertexArray(parent Doer, format AttrFormat, usage VertexUsage, vertexNum int, indices []int) (*VertexArray, error) {
+	return NewVertexArrayMulti(parent, []BufferBinding{{Format: format, Usage: usage}}, vertexNum, indices)
+}
+
+// NewVertexArrayMulti creates a new empty vertex array whose attributes are split across several
+// vertex buffers, one per BufferBinding, instead of a single interleaved buffer. This is useful
+// when attributes change at different rates: e.g. positions=StreamUsage, colors=StaticUsage.
+//
+// As with NewVertexArray, you cannot specify vertex data here, only the layout and count; use
+// SetVertexAttr to fill it in, and indices to specify how to combine vertices into triangles.
+func NewVertexArrayMulti(parent Doer, bindings []BufferBinding, vertexNum int, indices []int) (*VertexArray, error) {
 	va := &VertexArray{
 		parent: parent,
-		vao: binder{
-			restoreLoc: gl.VERTEX_ARRAY_BINDING,
-			bindFunc: func(obj uint32) {
-				gl.BindVertexArray(obj)
-			},
-		},
-		vbo: binder{
-			restoreLoc: gl.ARRAY_BUFFER_BINDING,
-			bindFunc: func(obj uint32) {
-				gl.BindBuffer(gl.ARRAY_BUFFER, obj)
-			},
-		},
 		ebo: binder{
 			restoreLoc: gl.ELEMENT_ARRAY_BUFFER_BINDING,
 			bindFunc: func(obj uint32) {
 				gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, obj)
 			},
 		},
-		vertexNum: vertexNum,
-		format:    format,
-		usage:     usage,
-		stride:    format.Size(),
-		offset:    make(map[string]int),
+		vertexNum:   vertexNum,
+		format:      make(AttrFormat),
+		attrBinding: make(map[string]int),
+		bindings:    make([]vertexBinding, len(bindings)),
+		mode:        Triangles,
 	}
 
-	offset := 0
-	for name, typ := range format {
-		switch typ {
-		case Float, Vec2, Vec3, Vec4:
-		default:
-			return nil, errors.New("failed to create vertex array: invalid vertex format: invalid attribute type")
+	for bi, binding := range bindings {
+		vb := vertexBinding{
+			format: binding.Format,
+			usage:  binding.Usage,
+			stride: binding.Format.Size(),
+			offset: make(map[string]int),
+			vbo: binder{
+				restoreLoc: gl.ARRAY_BUFFER_BINDING,
+				bindFunc: func(obj uint32) {
+					gl.BindBuffer(gl.ARRAY_BUFFER, obj)
+				},
+			},
+		}
+
+		offset := 0
+		for name, typ := range binding.Format {
+			if !validAttrType(parent, typ) {
+				return nil, errors.New("failed to create vertex array: invalid vertex format: invalid attribute type")
+			}
+			if _, exists := va.format[name]; exists {
+				return nil, errors.New("failed to create vertex array: attribute present in more than one buffer binding")
+			}
+			vb.offset[name] = offset
+			offset += typ.Size()
+			va.format[name] = typ
+			va.attrBinding[name] = bi
 		}
-		va.offset[name] = offset
-		offset += typ.Size()
+
+		va.bindings[bi] = vb
 	}
 
 	parent.Do(func(ctx Context) {
 		Do(func() {
-			gl.GenVertexArrays(1, &va.vao.obj)
-			va.vao.bind()
-
-			gl.GenBuffers(1, &va.vbo.obj)
-			defer va.vbo.bind().restore()
+			for bi := range va.bindings {
+				vb := &va.bindings[bi]
+				gl.GenBuffers(1, &vb.vbo.obj)
+				defer vb.vbo.bind().restore()
 
-			emptyData := make([]byte, vertexNum*va.stride)
-			gl.BufferData(gl.ARRAY_BUFFER, len(emptyData), gl.Ptr(emptyData), uint32(usage))
-
-			gl.GenBuffers(1, &va.ebo.obj)
-			defer va.ebo.bind().restore()
-
-			for name, typ := range format {
-				loc := gl.GetAttribLocation(ctx.Shader().ID(), gl.Str(name+"\x00"))
-
-				var size int32
-				switch typ {
-				case Float:
-					size = 1
-				case Vec2:
-					size = 2
-				case Vec3:
-					size = 3
-				case Vec4:
-					size = 4
-				}
-
-				gl.VertexAttribPointer(
-					uint32(loc),
-					size,
-					gl.FLOAT,
-					false,
-					int32(va.stride),
-					gl.PtrOffset(va.offset[name]),
-				)
-				gl.EnableVertexAttribArray(uint32(loc))
+				emptyData := make([]byte, vertexNum*vb.stride)
+				gl.BufferData(gl.ARRAY_BUFFER, len(emptyData), gl.Ptr(emptyData), uint32(vb.usage))
 			}
 
-			va.vao.restore()
+			gl.GenBuffers(1, &va.ebo.obj)
 		})
 	})
 
-	va.SetIndices(indices)
+	if indices != nil {
+		va.SetIndices(indices)
+	}
 
 	return va, nil
 }
@@ -130,16 +236,26 @@ func NewVertexArray(parent Doer, format AttrFormat, usage VertexUsage, vertexNum
 func (va *VertexArray) Delete() {
 	va.parent.Do(func(ctx Context) {
 		DoNoBlock(func() {
-			gl.DeleteVertexArrays(1, &va.vao.obj)
-			gl.DeleteBuffers(1, &va.vbo.obj)
+			ctx.vaoCache().invalidateVertexArray(va)
+			for bi := range va.bindings {
+				gl.DeleteBuffers(1, &va.bindings[bi].vbo.obj)
+			}
 			gl.DeleteBuffers(1, &va.ebo.obj)
+			if va.ivbo.obj != 0 {
+				gl.DeleteBuffers(1, &va.ivbo.obj)
+			}
 		})
 	})
 }
 
-// ID returns an OpenGL identifier of a vertex array.
+// ID returns an OpenGL identifier of a vertex array's first (or only) underlying vertex buffer
+// object.
+//
+// Note that a VertexArray no longer owns a single GL vertex array object: since the same vertex
+// buffer(s) can be drawn under multiple shader programs, each pairing gets its own cached VAO.
+// See the Context's vaoCache.
 func (va *VertexArray) ID() uint32 {
-	return va.vao.obj
+	return va.bindings[0].vbo.obj
 }
 
 // VertexNum returns the number of vertices in a vertex array.
@@ -154,9 +270,10 @@ func (va *VertexArray) VertexFormat() AttrFormat {
 	return va.format
 }
 
-// VertexUsage returns the usage of the verteices inside a vertex array.
+// VertexUsage returns the usage of the vertices inside a vertex array's first (or only) buffer
+// binding.
 func (va *VertexArray) VertexUsage() VertexUsage {
-	return va.usage
+	return va.bindings[0].usage
 }
 
 // Draw draws a vertex array.
@@ -164,21 +281,32 @@ func (va *VertexArray) Draw() {
 	va.Do(func(Context) {})
 }
 
-// SetIndices sets the indices of triangles to be drawn. Triangles will be formed from the vertices of the array
-// as defined by these indices. The first drawn triangle is specified by the first three indices, the second by
-// the fourth through sixth and so on.
+// SetPrimitive sets the primitive mode vertices are assembled into when drawing. The default,
+// set by NewVertexArray, is Triangles.
+//
+// Changing the mode affects the divisibility requirement enforced by SetIndices: Points requires
+// no particular multiple, Lines requires a multiple of 2, Triangles a multiple of 3, and the
+// strip/fan/loop modes accept any number of indices.
+func (va *VertexArray) SetPrimitive(mode PrimitiveMode) {
+	va.mode = mode
+}
+
+// SetIndices sets the indices of primitives to be drawn. Primitives will be formed from the
+// vertices of the array as defined by these indices and the array's primitive mode (Triangles by
+// default; see SetPrimitive).
 func (va *VertexArray) SetIndices(indices []int) {
-	if len(indices)%3 != 0 {
-		panic("vertex array set indices: number of indices not divisible by 3")
+	if n := va.mode.indexMultiple(); n > 0 && len(indices)%n != 0 {
+		panic("vertex array set indices: wrong number of indices for primitive mode")
 	}
 	indices32 := make([]uint32, len(indices))
 	for i := range indices32 {
 		indices32[i] = uint32(indices[i])
 	}
 	va.indexNum = len(indices32)
+	va.indexed = true
 	DoNoBlock(func() {
 		va.ebo.bind()
-		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, 4*len(indices32), gl.Ptr(indices32), uint32(va.usage))
+		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, 4*len(indices32), gl.Ptr(indices32), uint32(va.bindings[0].usage))
 		va.ebo.restore()
 	})
 }
@@ -189,11 +317,26 @@ func (va *VertexArray) SetIndices(indices []int) {
 // this method panics.
 //
 // Supplied value must correspond to the type of the attribute. Correct types are these (righ-hand is the type of the value):
-//   Attr{Type: Float}: float32
-//   Attr{Type: Vec2}:  mgl32.Vec2
-//   Attr{Type: Vec3}:  mgl32.Vec3
-//   Attr{Type: Vec4}:  mgl32.Vec4
+//   Attr{Type: Float}:       float32
+//   Attr{Type: Vec2}:        mgl32.Vec2
+//   Attr{Type: Vec3}:        mgl32.Vec3
+//   Attr{Type: Vec4}:        mgl32.Vec4
+//   Attr{Type: Int}:         int32
+//   Attr{Type: IVec2}:       [2]int32
+//   Attr{Type: IVec3}:       [3]int32
+//   Attr{Type: IVec4}:       [4]int32
+//   Attr{Type: Short}:       int16
+//   Attr{Type: UShort}:      uint16
+//   Attr{Type: UByte4Color}: [4]uint8, a normalized RGBA color packed into 4 bytes
+//   Attr{Type: Half2}:       mgl32.Vec2, stored as two GL_HALF_FLOAT components
+//   Attr{Type: Half3}:       mgl32.Vec3, stored as three GL_HALF_FLOAT components
+//   Attr{Type: Half4}:       mgl32.Vec4, stored as four GL_HALF_FLOAT components
 // No other types are supported.
+//
+// If called between Begin and End, the write goes into the mapped CPU-side mirror and is only
+// flushed to the GPU once End is called, instead of issuing a GL call immediately. Between Begin
+// and End, only attributes of the first buffer binding can be set; setting any other attribute
+// panics.
 func (va *VertexArray) SetVertexAttr(vertex int, attr Attr, value interface{}) (ok bool) {
 	if vertex < 0 || vertex >= va.vertexNum {
 		panic("set vertex attr: invalid vertex index")
@@ -203,32 +346,138 @@ func (va *VertexArray) SetVertexAttr(vertex int, attr Attr, value interface{}) (
 		return false
 	}
 
+	bi := va.attrBinding[attr.Name]
+	vb := &va.bindings[bi]
+	offset := vb.stride*vertex + vb.offset[attr.Name]
+	packed := packAttrValue(attr.Type, value)
+	va.writeVertexData(bi, offset, len(packed), unsafe.Pointer(&packed[0]))
+
+	return true
+}
+
+// writeVertexData writes size bytes from src into buffer binding bi at the given byte offset,
+// either into the mapped mirror (tracking the touched range for End to flush, bi must be 0) or,
+// outside of a Begin/End pair, directly via glBufferSubData.
+func (va *VertexArray) writeVertexData(bi, offset, size int, src unsafe.Pointer) {
+	if va.mapping {
+		if bi != 0 {
+			panic("vertex array: cannot write to a non-primary buffer binding while mapping")
+		}
+		copy(va.mapped[offset:offset+size], (*[1 << 30]byte)(src)[:size:size])
+		if offset < va.dirtyMin {
+			va.dirtyMin = offset
+		}
+		if offset+size > va.dirtyMax {
+			va.dirtyMax = offset + size
+		}
+		return
+	}
+
 	DoNoBlock(func() {
-		va.vbo.bind()
-
-		offset := va.stride*vertex + va.offset[attr.Name]
-
-		switch attr.Type {
-		case Float:
-			value := value.(float32)
-			gl.BufferSubData(gl.ARRAY_BUFFER, offset, attr.Type.Size(), unsafe.Pointer(&value))
-		case Vec2:
-			value := value.(mgl32.Vec2)
-			gl.BufferSubData(gl.ARRAY_BUFFER, offset, attr.Type.Size(), unsafe.Pointer(&value))
-		case Vec3:
-			value := value.(mgl32.Vec3)
-			gl.BufferSubData(gl.ARRAY_BUFFER, offset, attr.Type.Size(), unsafe.Pointer(&value))
-		case Vec4:
-			value := value.(mgl32.Vec4)
-			gl.BufferSubData(gl.ARRAY_BUFFER, offset, attr.Type.Size(), unsafe.Pointer(&value))
-		default:
-			panic("set vertex attr: invalid attribute type")
+		vb := &va.bindings[bi]
+		vb.vbo.bind()
+		gl.BufferSubData(gl.ARRAY_BUFFER, offset, size, src)
+		vb.vbo.restore()
+	})
+}
+
+// Begin maps the first (or only) buffer binding's VBO into memory so that subsequent
+// SetVertexAttr calls targeting it write into a CPU-side mirror instead of issuing a
+// glBufferSubData call each time. Call End to flush the touched range back to the GPU in a
+// single call and unmap the buffer.
+//
+// Begin/End is intended for code that updates many vertices per frame (e.g. a sprite batcher);
+// for occasional updates, plain SetVertexAttr calls are simpler and perform just as well. It only
+// covers the first buffer binding; attributes in other bindings of a multi-buffer vertex array
+// (see NewVertexArrayMulti) must be written with plain SetVertexAttr calls outside of Begin/End;
+// SetVertexAttr on them between Begin and End panics.
+func (va *VertexArray) Begin() {
+	if va.mapping {
+		panic("vertex array begin: already mapping")
+	}
+
+	ensureMapBufferRangeChecked(va.parent)
+
+	vb := &va.bindings[0]
+	size := vb.stride * va.vertexNum
+	va.mapping = true
+	va.dirtyMin = size
+	va.dirtyMax = 0
+
+	// Do, not DoNoBlock: the caller's next line is typically SetVertexAttr, which indexes into
+	// va.mapped immediately, so it must be set before Begin returns.
+	Do(func() {
+		vb.vbo.bind()
+		if mapBufferRangeSupported {
+			// No GL_MAP_INVALIDATE_RANGE_BIT: that would let the driver discard the *entire*
+			// mapped range, and with MAP_FLUSH_EXPLICIT_BIT only the sub-range flushed by End is
+			// guaranteed written back, corrupting every vertex this Begin/End doesn't touch.
+			ptr := gl.MapBufferRange(
+				gl.ARRAY_BUFFER,
+				0,
+				size,
+				gl.MAP_WRITE_BIT|gl.MAP_FLUSH_EXPLICIT_BIT,
+			)
+			va.mapped = (*[1 << 30]byte)(ptr)[:size:size]
+		} else {
+			// Start from the buffer's current contents: End uploads the whole span between the
+			// first and last touched byte, including any untouched vertices inside it.
+			va.mapped = make([]byte, size)
+			if size > 0 {
+				gl.GetBufferSubData(gl.ARRAY_BUFFER, 0, size, unsafe.Pointer(&va.mapped[0]))
+			}
 		}
+		vb.vbo.restore()
+	})
+}
 
-		va.vbo.restore()
+// End flushes the range of the first buffer binding's VBO touched since Begin to the GPU and
+// unmaps it. It is invalid to call Begin/End recursively, or to call End without a matching
+// Begin.
+func (va *VertexArray) End() {
+	if !va.mapping {
+		panic("vertex array end: not mapping")
+	}
+	va.mapping = false
+
+	vb := &va.bindings[0]
+	DoNoBlock(func() {
+		vb.vbo.bind()
+		if mapBufferRangeSupported {
+			if va.dirtyMax > va.dirtyMin {
+				gl.FlushMappedBufferRange(gl.ARRAY_BUFFER, va.dirtyMin, va.dirtyMax-va.dirtyMin)
+			}
+			gl.UnmapBuffer(gl.ARRAY_BUFFER)
+		} else if va.dirtyMax > va.dirtyMin {
+			gl.BufferSubData(gl.ARRAY_BUFFER, va.dirtyMin, va.dirtyMax-va.dirtyMin, unsafe.Pointer(&va.mapped[va.dirtyMin]))
+		}
+		vb.vbo.restore()
 	})
 
-	return true
+	va.mapped = nil
+}
+
+// SetVertices writes data into a contiguous range of the first (or only) buffer binding in a
+// single GL call, starting at the given vertex index. len(data) must be a multiple of that
+// binding's vertex stride (in float32s), and the written range must lie fully inside the vertex
+// array.
+//
+// SetVertices bypasses the attribute format and writes raw float32 values directly, so the
+// caller is responsible for matching that binding's vertex layout.
+func (va *VertexArray) SetVertices(start int, data []float32) {
+	if start < 0 || start >= va.vertexNum {
+		panic("vertex array set vertices: invalid start index")
+	}
+
+	stride := va.bindings[0].stride
+	byteOffset := start * stride
+	byteSize := len(data) * 4
+
+	if byteOffset+byteSize > va.vertexNum*stride {
+		panic("vertex array set vertices: data out of range")
+	}
+
+	va.writeVertexData(0, byteOffset, byteSize, gl.Ptr(data))
 }
 
 // VertexAttr returns the current value of the specified vertex attribute of the specified vertex.
@@ -247,47 +496,49 @@ func (va *VertexArray) VertexAttr(vertex int, attr Attr) (value interface{}, ok
 	}
 
 	Do(func() {
-		va.vbo.bind()
-
-		offset := va.stride*vertex + va.offset[attr.Name]
-
-		switch attr.Type {
-		case Float:
-			var data float32
-			gl.GetBufferSubData(gl.ARRAY_BUFFER, offset, attr.Type.Size(), unsafe.Pointer(&data))
-			value = data
-		case Vec2:
-			var data mgl32.Vec2
-			gl.GetBufferSubData(gl.ARRAY_BUFFER, offset, attr.Type.Size(), unsafe.Pointer(&data))
-			value = data
-		case Vec3:
-			var data mgl32.Vec3
-			gl.GetBufferSubData(gl.ARRAY_BUFFER, offset, attr.Type.Size(), unsafe.Pointer(&data))
-			value = data
-		case Vec4:
-			var data mgl32.Vec4
-			gl.GetBufferSubData(gl.ARRAY_BUFFER, offset, attr.Type.Size(), unsafe.Pointer(&data))
-			value = data
-		default:
-			panic("set vertex attr: invalid attribute type")
-		}
+		vb := &va.bindings[va.attrBinding[attr.Name]]
+		vb.vbo.bind()
 
-		va.vbo.restore()
+		offset := vb.stride*vertex + vb.offset[attr.Name]
+		data := make([]byte, attr.Type.Size())
+		gl.GetBufferSubData(gl.ARRAY_BUFFER, offset, len(data), unsafe.Pointer(&data[0]))
+		value = unpackAttrValue(attr.Type, data)
+
+		vb.vbo.restore()
 	})
 
 	return value, true
 }
 
+// bindVAO binds the cached VAO for va under ctx, returning a binder that later restores whatever
+// VAO was bound before. Shared by Do and DrawInstanced, the two methods that draw va.
+func (va *VertexArray) bindVAO(ctx Context) binder {
+	var vao binder
+	DoNoBlock(func() {
+		vao = binder{
+			obj:        ctx.vaoCache().vaoFor(va, ctx),
+			restoreLoc: gl.VERTEX_ARRAY_BINDING,
+			bindFunc: func(obj uint32) {
+				gl.BindVertexArray(obj)
+			},
+		}
+		vao.bind()
+	})
+	return vao
+}
+
 // Do binds a vertex arrray and it's associated vertex buffer, executes sub, and unbinds the vertex array and it's vertex buffer.
 func (va *VertexArray) Do(sub func(Context)) {
 	va.parent.Do(func(ctx Context) {
-		DoNoBlock(func() {
-			va.vao.bind()
-		})
+		vao := va.bindVAO(ctx)
 		sub(ctx)
 		DoNoBlock(func() {
-			gl.DrawElements(gl.TRIANGLES, int32(va.indexNum), gl.UNSIGNED_INT, gl.PtrOffset(0))
-			va.vao.restore()
+			if va.indexed {
+				gl.DrawElements(uint32(va.mode), int32(va.indexNum), gl.UNSIGNED_INT, gl.PtrOffset(0))
+			} else {
+				gl.DrawArrays(uint32(va.mode), 0, int32(va.vertexNum))
+			}
+			vao.restore()
 		})
 	})
 }